@@ -0,0 +1,354 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnstructuredConverter knows how to round-trip a typed Object through the
+// generic map[string]interface{} representation that decoding arbitrary
+// JSON produces. This is useful for sinks and controllers that need to
+// handle CRDs or other payloads whose Go type isn't known ahead of time.
+type UnstructuredConverter interface {
+	ToUnstructured(obj interface{}) (map[string]interface{}, error)
+	FromUnstructured(u map[string]interface{}, obj interface{}) error
+}
+
+// DefaultUnstructuredConverter is the UnstructuredConverter used when
+// callers don't need anything custom. It walks struct fields with
+// reflection, honoring the same `json:"name,omitempty"` and `json:"-"`
+// tags encoding/json does.
+var DefaultUnstructuredConverter UnstructuredConverter = &unstructuredConverter{}
+
+type unstructuredConverter struct {
+	// fieldCache memoizes the []fieldInfo computed for each reflect.Type
+	// so repeated conversions of the same struct type don't re-walk its
+	// fields and tags every time.
+	fieldCache sync.Map
+}
+
+type fieldInfo struct {
+	name      string
+	index     []int
+	omitempty bool
+}
+
+func (c *unstructuredConverter) fieldsFor(t reflect.Type) []fieldInfo {
+	if cached, ok := c.fieldCache.Load(t); ok {
+		return cached.([]fieldInfo)
+	}
+	fields := computeFields(t)
+	c.fieldCache.Store(t, fields)
+	return fields
+}
+
+// computeFields walks t's fields, honoring `json` tags, and splices the
+// fields of anonymous (embedded) structs in at the embedding field's index
+// prefix so they appear as if they were declared directly on t. This is
+// what flattens TypeMeta/ObjectMeta's `kind`/`apiVersion`/`name`/... up to
+// the top level of a k8s object instead of nesting them under "TypeMeta".
+func computeFields(t reflect.Type) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		omitempty := false
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" {
+				omitempty = true
+			}
+		}
+		// An anonymous struct field flattens into its parent's fields when
+		// it has no explicit name override: either no tag at all, or a
+		// tag like `json:",inline"` (the convention k8s types use to embed
+		// TypeMeta/ObjectMeta) whose name segment is empty.
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for _, nested := range computeFields(f.Type) {
+				fields = append(fields, fieldInfo{
+					name:      nested.name,
+					index:     append([]int{i}, nested.index...),
+					omitempty: nested.omitempty,
+				})
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, fieldInfo{name: name, index: []int{i}, omitempty: omitempty})
+	}
+	return fields
+}
+
+// ToUnstructured converts obj, which must be a struct or a pointer to one,
+// into a map[string]interface{} whose keys match what json.Marshal(obj)
+// would have produced.
+func (c *unstructuredConverter) ToUnstructured(obj interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot convert %T to unstructured: not a struct", obj)
+	}
+	fields := c.fieldsFor(v.Type())
+	out := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		value, err := c.toUnstructuredValue(v.FieldByIndex(f.index))
+		if err != nil {
+			return nil, err
+		}
+		out[f.name] = value
+	}
+	return sanitize(out, fields, v), nil
+}
+
+// sanitize deletes entries whose json tag set omitempty and whose backing
+// struct field held its zero value, so ToUnstructured's map output matches
+// what json.Marshal would have produced instead of including every field.
+func sanitize(out map[string]interface{}, fields []fieldInfo, v reflect.Value) map[string]interface{} {
+	for _, f := range fields {
+		if f.omitempty && isEmptyValue(v.FieldByIndex(f.index)) {
+			delete(out, f.name)
+		}
+	}
+	return out
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (c *unstructuredConverter) toUnstructuredValue(v reflect.Value) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return c.toUnstructuredValue(v.Elem())
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).UTC().Format(time.RFC3339), nil
+		}
+		return c.ToUnstructured(v.Interface())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			value, err := c.toUnstructuredValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = value
+		}
+		return out, nil
+	case reflect.Map:
+		out := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			value, err := c.toUnstructuredValue(v.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+			out[fmt.Sprintf("%v", key.Interface())] = value
+		}
+		return out, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	default:
+		return v.Interface(), nil
+	}
+}
+
+// FromUnstructured decodes u into obj, which must be a non-nil pointer to
+// a struct. JSON numbers in u are float64, matching what encoding/json
+// produces when decoding into interface{}; FromUnstructured coerces them
+// back to the destination field's actual int64/uint/float kind.
+func (c *unstructuredConverter) FromUnstructured(u map[string]interface{}, obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("FromUnstructured requires a non-nil pointer, got %T", obj)
+	}
+	return c.fromUnstructuredStruct(u, v.Elem())
+}
+
+func (c *unstructuredConverter) fromUnstructuredStruct(u map[string]interface{}, v reflect.Value) error {
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode into %s: not a struct", v.Type())
+	}
+	for _, f := range c.fieldsFor(v.Type()) {
+		raw, ok := u[f.name]
+		if !ok {
+			continue
+		}
+		if err := c.fromUnstructuredValue(raw, v.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("field %s: %v", f.name, err)
+		}
+	}
+	return nil
+}
+
+func (c *unstructuredConverter) fromUnstructuredValue(raw interface{}, v reflect.Value) error {
+	if raw == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return c.fromUnstructuredValue(raw, v.Elem())
+	}
+	if v.Type() == timeType {
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected RFC3339 string for time.Time, got %T", raw)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected base64 string for []byte, got %T", raw)
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		v.SetBytes(data)
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %T", raw)
+		}
+		return c.fromUnstructuredStruct(m, v)
+	case reflect.Slice:
+		list, ok := raw.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected array, got %T", raw)
+		}
+		out := reflect.MakeSlice(v.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := c.fromUnstructuredValue(item, out.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(out)
+		return nil
+	case reflect.Map:
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %T", raw)
+		}
+		out := reflect.MakeMapWithSize(v.Type(), len(m))
+		for key, val := range m {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := c.fromUnstructuredValue(val, elem); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(out)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := raw.(float64)
+		if !ok {
+			return fmt.Errorf("expected number, got %T", raw)
+		}
+		v.SetInt(int64(f))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := raw.(float64)
+		if !ok {
+			return fmt.Errorf("expected number, got %T", raw)
+		}
+		v.SetUint(uint64(f))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := raw.(float64)
+		if !ok {
+			return fmt.Errorf("expected number, got %T", raw)
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return fmt.Errorf("expected bool, got %T", raw)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("expected string, got %T", raw)
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(raw))
+		return nil
+	default:
+		return fmt.Errorf("unsupported kind %s for FromUnstructured", v.Kind())
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type FakeTypeMeta struct {
+	Kind       string `json:"kind,omitempty"`
+	APIVersion string `json:"apiVersion,omitempty"`
+}
+
+type FakeObjectMeta struct {
+	Name string `json:"name,omitempty"`
+}
+
+// fakePod mirrors how real k8s types embed TypeMeta/ObjectMeta with
+// `json:",inline"` so their fields appear at the top level of the encoded
+// object instead of nested under "TypeMeta"/"ObjectMeta".
+type fakePod struct {
+	FakeTypeMeta   `json:",inline"`
+	FakeObjectMeta `json:"metadata,omitempty"`
+	Replicas       int               `json:"replicas"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	Data           []byte            `json:"data,omitempty"`
+	Created        time.Time         `json:"created,omitempty"`
+	Ignored        string            `json:"-"`
+}
+
+func TestToUnstructuredFlattensInlineEmbeds(t *testing.T) {
+	pod := &fakePod{
+		FakeTypeMeta: FakeTypeMeta{Kind: "Pod", APIVersion: "v1"},
+		Replicas:     3,
+	}
+	u, err := DefaultUnstructuredConverter.ToUnstructured(pod)
+	if err != nil {
+		t.Fatalf("ToUnstructured: %v", err)
+	}
+	if u["kind"] != "Pod" || u["apiVersion"] != "v1" {
+		t.Errorf("expected kind/apiVersion flattened to top level, got %#v", u)
+	}
+	if _, nested := u["FakeTypeMeta"]; nested {
+		t.Errorf("FakeTypeMeta should not appear nested, got %#v", u)
+	}
+	// FakeObjectMeta is embedded with an explicit name ("metadata"), so
+	// unlike the inline TypeMeta it nests as its own object rather than
+	// flattening; struct fields are never treated as "empty" by omitempty
+	// (the same quirk encoding/json has), so the key itself still appears.
+	metadata, ok := u["metadata"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected metadata to be a nested object, got %#v", u["metadata"])
+	}
+	if _, present := metadata["name"]; present {
+		t.Errorf("empty metadata.name should be omitted by omitempty, got %#v", metadata)
+	}
+	if _, present := u["Ignored"]; present {
+		t.Errorf("json:\"-\" field should never be present, got %#v", u)
+	}
+}
+
+func TestToUnstructuredFromUnstructuredRoundTrip(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	pod := &fakePod{
+		FakeTypeMeta:   FakeTypeMeta{Kind: "Pod", APIVersion: "v1"},
+		FakeObjectMeta: FakeObjectMeta{Name: "my-pod"},
+		Replicas:       2,
+		Labels:         map[string]string{"app": "heapster"},
+		Tags:           []string{"a", "b"},
+		Data:           []byte("hello"),
+		Created:        created,
+	}
+
+	u, err := DefaultUnstructuredConverter.ToUnstructured(pod)
+	if err != nil {
+		t.Fatalf("ToUnstructured: %v", err)
+	}
+
+	var out fakePod
+	if err := DefaultUnstructuredConverter.FromUnstructured(u, &out); err != nil {
+		t.Fatalf("FromUnstructured: %v", err)
+	}
+
+	out.Created = out.Created.UTC()
+	if !reflect.DeepEqual(*pod, out) {
+		t.Errorf("round trip mismatch:\n got  %#v\n want %#v", out, *pod)
+	}
+}
+
+func TestToUnstructuredNumberCoercion(t *testing.T) {
+	type counters struct {
+		Count int64 `json:"count"`
+	}
+	// JSON numbers decode as float64; FromUnstructured must coerce back.
+	u := map[string]interface{}{"count": float64(42)}
+	var out counters
+	if err := DefaultUnstructuredConverter.FromUnstructured(u, &out); err != nil {
+		t.Fatalf("FromUnstructured: %v", err)
+	}
+	if out.Count != 42 {
+		t.Errorf("Count = %d, want 42", out.Count)
+	}
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Framer creates readers and writers that obey a particular streaming
+// framing protocol, so that multiple objects encoded back to back can be
+// split apart again on the way in. NewFrameReader/NewFrameWriter wrap an
+// underlying stream; they do not themselves know how to encode or decode
+// the objects carried inside each frame.
+type Framer interface {
+	NewFrameReader(r io.ReadCloser) io.ReadCloser
+	NewFrameWriter(w io.Writer) io.Writer
+}
+
+var _ Framer = defaultFramer{}
+
+// FrameReader is implemented by the io.ReadCloser a Framer hands back when
+// it can identify a frame's boundary on its own. DecodeStreamList prefers
+// NextFrame over chunking through Read directly, since Read is free to
+// return less than a whole frame (a single call never returns more than
+// the caller's buffer, and the length-delimited reader can also stop at
+// what its bufio.Reader currently holds), which would otherwise split one
+// large object across multiple spurious "frames". Framers with no concept
+// of a boundary, like DefaultFramer, do not implement it.
+type FrameReader interface {
+	io.ReadCloser
+	NextFrame() ([]byte, error)
+}
+
+// LengthDelimitedFramer frames each object with a varint-encoded length
+// prefix followed by exactly that many bytes of payload. Because the frame
+// boundary does not depend on the payload's own syntax, a single
+// length-delimited stream can carry a mix of encodings (for example
+// protobuf-wrapped Unknown frames next to JSON ones) as long as whatever
+// decodes a frame's payload consults that payload's own ContentType.
+var LengthDelimitedFramer = lengthDelimitedFramer{}
+
+type lengthDelimitedFramer struct{}
+
+func (lengthDelimitedFramer) NewFrameReader(r io.ReadCloser) io.ReadCloser {
+	return &lengthDelimitedFrameReader{r: bufio.NewReader(r), closer: r}
+}
+
+func (lengthDelimitedFramer) NewFrameWriter(w io.Writer) io.Writer {
+	return &lengthDelimitedFrameWriter{w: w}
+}
+
+type lengthDelimitedFrameWriter struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *lengthDelimitedFrameWriter) Write(data []byte) (int, error) {
+	n := binary.PutUvarint(w.buf[:], uint64(len(data)))
+	if _, err := w.w.Write(w.buf[:n]); err != nil {
+		return 0, err
+	}
+	return w.w.Write(data)
+}
+
+type lengthDelimitedFrameReader struct {
+	r         *bufio.Reader
+	closer    io.Closer
+	remaining int
+}
+
+// Read consumes the varint length prefix of the next frame the first time
+// it is called for that frame, then returns up to len(data) bytes of the
+// frame's payload, never crossing into the following frame.
+func (r *lengthDelimitedFrameReader) Read(data []byte) (int, error) {
+	if r.remaining <= 0 {
+		length, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return 0, err
+		}
+		r.remaining = int(length)
+	}
+	if r.remaining == 0 {
+		return 0, nil
+	}
+	max := len(data)
+	if r.remaining < max {
+		max = r.remaining
+	}
+	n, err := r.r.Read(data[:max])
+	r.remaining -= n
+	return n, err
+}
+
+func (r *lengthDelimitedFrameReader) Close() error {
+	return r.closer.Close()
+}
+
+// NextFrame reads the varint length prefix of the next frame (if Read
+// hasn't already started one) and returns that many bytes in a single
+// allocation, regardless of how large the frame is.
+func (r *lengthDelimitedFrameReader) NextFrame() ([]byte, error) {
+	if r.remaining <= 0 {
+		length, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return nil, err
+		}
+		r.remaining = int(length)
+	}
+	frame := make([]byte, r.remaining)
+	if _, err := io.ReadFull(r.r, frame); err != nil {
+		return nil, err
+	}
+	r.remaining = 0
+	return frame, nil
+}
+
+var _ FrameReader = &lengthDelimitedFrameReader{}
+
+// JSONFramer frames a stream of concatenated JSON values (e.g.
+// `{"a":1}{"b":2}`) by walking the top-level token boundaries of each value
+// with json.Decoder.Token, rather than assuming whitespace or newline
+// separators. It is the framer used for content types like
+// application/json where the payload is already self-delimiting.
+var JSONFramer = jsonFramer{}
+
+type jsonFramer struct{}
+
+func (jsonFramer) NewFrameReader(r io.ReadCloser) io.ReadCloser {
+	raw := &bytes.Buffer{}
+	return &jsonFrameReader{
+		closer:  r,
+		raw:     raw,
+		decoder: json.NewDecoder(io.TeeReader(r, raw)),
+	}
+}
+
+func (jsonFramer) NewFrameWriter(w io.Writer) io.Writer {
+	// JSON values are self-delimiting on the wire, so writing is a no-op;
+	// the reader does the work of splitting them back apart.
+	return w
+}
+
+type jsonFrameReader struct {
+	closer    io.Closer
+	raw       *bytes.Buffer
+	decoder   *json.Decoder
+	offset    int64
+	remaining []byte
+}
+
+func (r *jsonFrameReader) Read(data []byte) (int, error) {
+	if len(r.remaining) == 0 {
+		frame, err := r.nextFrame()
+		if err != nil {
+			return 0, err
+		}
+		r.remaining = frame
+	}
+	n := copy(data, r.remaining)
+	r.remaining = r.remaining[n:]
+	return n, nil
+}
+
+// nextFrame walks tokens until the bracket/brace depth returns to zero,
+// then slices the raw bytes the decoder consumed for that one JSON value
+// out of the buffer the TeeReader filled in.
+func (r *jsonFrameReader) nextFrame() ([]byte, error) {
+	depth := 0
+	started := false
+	for {
+		tok, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := tok.(json.Delim); ok {
+			switch delim {
+			case '{', '[':
+				depth++
+				started = true
+			case '}', ']':
+				depth--
+			}
+		} else {
+			started = true
+		}
+		if started && depth == 0 {
+			break
+		}
+	}
+	end := r.decoder.InputOffset()
+	frame := r.raw.Next(int(end - r.offset))
+	r.offset = end
+	return frame, nil
+}
+
+func (r *jsonFrameReader) Close() error {
+	return r.closer.Close()
+}
+
+// NextFrame returns the unread rest of the JSON value a previous Read call
+// started consuming, or the next whole JSON value if no value is partly read.
+func (r *jsonFrameReader) NextFrame() ([]byte, error) {
+	if len(r.remaining) > 0 {
+		frame := r.remaining
+		r.remaining = nil
+		return frame, nil
+	}
+	return r.nextFrame()
+}
+
+var _ FrameReader = &jsonFrameReader{}
+
+// FramerRegistry maps a content type to the Framer that knows how to split
+// (or join) a stream of objects encoded with that content type, so that
+// EncodeListToStream/DecodeStreamList can write and read a list as one
+// framed stream instead of buffering the whole array in memory. Each of
+// those calls uses a single content type, and so a single Framer, for
+// every object on the stream; a list mixing encodings is not handled.
+type FramerRegistry struct {
+	mu      sync.RWMutex
+	framers map[string]Framer
+}
+
+// NewFramerRegistry returns a FramerRegistry with no framers registered.
+func NewFramerRegistry() *FramerRegistry {
+	return &FramerRegistry{framers: make(map[string]Framer)}
+}
+
+// Register associates contentType with framer, overwriting any previous
+// registration for that content type.
+func (r *FramerRegistry) Register(contentType string, framer Framer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.framers[contentType] = framer
+}
+
+// FramerFor returns the Framer registered for contentType, if any.
+func (r *FramerRegistry) FramerFor(contentType string) (Framer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	f, ok := r.framers[contentType]
+	return f, ok
+}
+
+// DefaultFramerRegistry is pre-populated with the framers this package
+// ships, keyed by the content types EncodeList/DecodeList already
+// understand via Unknown.ContentType.
+var DefaultFramerRegistry = newDefaultFramerRegistry()
+
+func newDefaultFramerRegistry() *FramerRegistry {
+	r := NewFramerRegistry()
+	r.Register("", DefaultFramer)
+	r.Register("application/json", JSONFramer)
+	r.Register("application/vnd.kubernetes.protobuf", LengthDelimitedFramer)
+	return r
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+func TestLengthDelimitedFramerRoundTrip(t *testing.T) {
+	frames := [][]byte{
+		[]byte("first"),
+		[]byte(""),
+		[]byte("a much longer second frame with several words in it"),
+	}
+
+	buf := &bytes.Buffer{}
+	w := LengthDelimitedFramer.NewFrameWriter(buf)
+	for _, f := range frames {
+		if _, err := w.Write(f); err != nil {
+			t.Fatalf("Write(%q): %v", f, err)
+		}
+	}
+
+	r := LengthDelimitedFramer.NewFrameReader(nopCloser{buf})
+	defer r.Close()
+	for i, want := range frames {
+		got := make([]byte, len(want))
+		if len(want) > 0 {
+			if _, err := io.ReadFull(r, got); err != nil {
+				t.Fatalf("frame %d: ReadFull: %v", i, err)
+			}
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("frame %d = %q, want %q", i, got, want)
+		}
+	}
+	if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected io.EOF after last frame, got %v", err)
+	}
+}
+
+func TestLengthDelimitedFramerSmallReadBuffer(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := LengthDelimitedFramer.NewFrameWriter(buf)
+	payload := []byte("0123456789")
+	if _, err := w.Write(payload); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	r := LengthDelimitedFramer.NewFrameReader(nopCloser{buf})
+	defer r.Close()
+	got, err := ioutil.ReadAll(&smallChunkReader{r: r, chunk: 3})
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("got %q, want %q", got, payload)
+	}
+}
+
+// smallChunkReader forces small Read calls onto r so frame reassembly
+// across multiple partial reads of a single frame is exercised.
+type smallChunkReader struct {
+	r     io.Reader
+	chunk int
+}
+
+func (s *smallChunkReader) Read(p []byte) (int, error) {
+	if len(p) > s.chunk {
+		p = p[:s.chunk]
+	}
+	return s.r.Read(p)
+}
+
+func TestJSONFramerSplitsConcatenatedValues(t *testing.T) {
+	// No whitespace between values: each frame's bytes match the value
+	// exactly, which is the shape a watch stream actually emits.
+	stream := `{"a":1}{"b":[1,2,3]}{"c":{"nested":true}}`
+	r := JSONFramer.NewFrameReader(nopCloser{bytes.NewBufferString(stream)})
+	defer r.Close()
+
+	want := []string{`{"a":1}`, `{"b":[1,2,3]}`, `{"c":{"nested":true}}`}
+	for i, w := range want {
+		got := make([]byte, len(w))
+		if _, err := io.ReadFull(r, got); err != nil {
+			t.Fatalf("frame %d: ReadFull: %v", i, err)
+		}
+		if string(got) != w {
+			t.Errorf("frame %d = %q, want %q", i, got, w)
+		}
+	}
+	if _, err := r.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected io.EOF after last frame, got %v", err)
+	}
+}
+
+// TestJSONFramerToleratesInterveningWhitespace covers the case where values
+// are separated by whitespace rather than back to back: json.Decoder.Token
+// consumes that whitespace while locating the next value's tokens, so it
+// ends up as a leading prefix of the following frame rather than being
+// dropped. That is harmless to any JSON parser reading the frame, so this
+// test decodes each frame instead of comparing raw bytes.
+func TestJSONFramerToleratesInterveningWhitespace(t *testing.T) {
+	stream := `{"a":1}   {"b":2}` + "\n" + `{"c":3}`
+	r := JSONFramer.NewFrameReader(nopCloser{bytes.NewBufferString(stream)})
+	defer r.Close()
+
+	var got []map[string]int
+	buf := make([]byte, 64)
+	for i := 0; i < 3; i++ {
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatalf("frame %d: Read: %v", i, err)
+		}
+		var obj map[string]int
+		if err := json.Unmarshal(buf[:n], &obj); err != nil {
+			t.Fatalf("frame %d: Unmarshal(%q): %v", i, buf[:n], err)
+		}
+		got = append(got, obj)
+	}
+	want := []map[string]int{{"a": 1}, {"b": 2}, {"c": 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFramerRegistry(t *testing.T) {
+	r := NewFramerRegistry()
+	if _, ok := r.FramerFor("application/json"); ok {
+		t.Fatalf("expected empty registry to have no framers registered")
+	}
+
+	r.Register("application/json", JSONFramer)
+	framer, ok := r.FramerFor("application/json")
+	if !ok || framer != Framer(JSONFramer) {
+		t.Fatalf("FramerFor(application/json) = %v, %v; want JSONFramer, true", framer, ok)
+	}
+
+	if _, ok := DefaultFramerRegistry.FramerFor("application/vnd.kubernetes.protobuf"); !ok {
+		t.Fatalf("expected DefaultFramerRegistry to have a framer for the protobuf content type")
+	}
+	if _, ok := DefaultFramerRegistry.FramerFor(""); !ok {
+		t.Fatalf("expected DefaultFramerRegistry to have a framer for the empty content type")
+	}
+}
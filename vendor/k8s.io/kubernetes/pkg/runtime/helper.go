@@ -19,6 +19,7 @@ package runtime
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"reflect"
 
 	"k8s.io/kubernetes/pkg/api/unversioned"
@@ -92,25 +93,62 @@ func FieldPtr(v reflect.Value, fieldName string, dest interface{}) error {
 	return fmt.Errorf("couldn't assign/convert %v to %v", field.Type(), v.Type())
 }
 
+// ContentTyper is implemented by Encoders and Decoders that can report the
+// content type of the bytes they produce or consume, so that EncodeList can
+// tag the Unknown objects it creates and decodeListItem can pick a decoder
+// directly instead of guessing.
+type ContentTyper interface {
+	ContentType() string
+}
+
 // EncodeList ensures that each object in an array is converted to a Unknown{} in serialized form.
-// TODO: accept a content type.
 func EncodeList(e Encoder, objects []Object, overrides ...unversioned.GroupVersion) error {
 	var errs []error
+	contentType := ""
+	if typer, ok := e.(ContentTyper); ok {
+		contentType = typer.ContentType()
+	}
 	for i := range objects {
 		data, err := Encode(e, objects[i], overrides...)
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
-		// TODO: Set ContentEncoding and ContentType.
-		objects[i] = &Unknown{Raw: data}
+		objects[i] = &Unknown{Raw: data, ContentType: contentType}
 	}
 	return errors.NewAggregate(errs)
 }
 
 func decodeListItem(obj *Unknown, decoders []Decoder) (Object, error) {
+	// Prefer a decoder that declares the same content type as the item
+	// itself; this is the common case once EncodeList has tagged the
+	// Unknown with ContentType, and avoids trying decoders that are known
+	// up front not to apply.
+	if obj.ContentType != "" {
+		for _, decoder := range decoders {
+			typer, ok := decoder.(ContentTyper)
+			if !ok || typer.ContentType() != obj.ContentType {
+				continue
+			}
+			decoded, err := Decode(decoder, obj.Raw)
+			if err != nil {
+				if IsNotRegisteredError(err) {
+					continue
+				}
+				return nil, err
+			}
+			return decoded, nil
+		}
+	}
+	// Ask any decoder that can sniff its own format whether it recognizes
+	// these bytes, rather than trying every decoder in turn. This avoids
+	// paying O(len(decoders)) decode attempts per item and stops a
+	// malformed payload for decoder A from being silently swallowed just
+	// because decoder B happened to come first and also failed.
+	if decoded, ok, err := decodeRecognized(obj.Raw, decoders); ok {
+		return decoded, err
+	}
 	for _, decoder := range decoders {
-		// TODO: Decode based on ContentType.
 		obj, err := Decode(decoder, obj.Raw)
 		if err != nil {
 			if IsNotRegisteredError(err) {
@@ -149,29 +187,124 @@ func DecodeList(objects []Object, decoders ...Decoder) []error {
 	return errs
 }
 
-// MultiObjectTyper returns the types of objects across multiple schemes in order.
-type MultiObjectTyper []ObjectTyper
-
-var _ ObjectTyper = MultiObjectTyper{}
+// EncodeListToStream writes objects to w as a single framed stream instead
+// of the in-memory []Object slice EncodeList produces. It looks up e's
+// content type in registry (DefaultFramerRegistry if registry is nil) to
+// find the Framer that knows how to delimit that content type's payloads,
+// so a length-delimited stream can carry protobuf frames while a
+// JSON-framed one carries concatenated JSON values, without buffering the
+// whole list in memory first.
+func EncodeListToStream(w io.Writer, registry *FramerRegistry, e Encoder, objects []Object, overrides ...unversioned.GroupVersion) error {
+	if registry == nil {
+		registry = DefaultFramerRegistry
+	}
+	contentType := ""
+	if typer, ok := e.(ContentTyper); ok {
+		contentType = typer.ContentType()
+	}
+	framer, ok := registry.FramerFor(contentType)
+	if !ok {
+		framer = DefaultFramer
+	}
+	fw := framer.NewFrameWriter(w)
 
-func (m MultiObjectTyper) ObjectKind(obj Object) (gvk unversioned.GroupVersionKind, err error) {
-	for _, t := range m {
-		gvk, err = t.ObjectKind(obj)
-		if err == nil {
-			return
+	var errs []error
+	for i := range objects {
+		data, err := Encode(e, objects[i], overrides...)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, err := fw.Write(data); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	return
+	return errors.NewAggregate(errs)
 }
 
-func (m MultiObjectTyper) ObjectKinds(obj Object) (gvks []unversioned.GroupVersionKind, err error) {
-	for _, t := range m {
-		gvks, err = t.ObjectKinds(obj)
-		if err == nil {
-			return
+// DecodeStreamList reads a stream produced by EncodeListToStream (or any
+// other producer that frames contentType the same way), splitting it back
+// into frames with the Framer registry associates with contentType
+// (DefaultFramerRegistry if registry is nil), then decoding each frame the
+// same way decodeListItem does for DecodeList's in-memory objects. Errors
+// decoding individual frames are collected and returned alongside whatever
+// objects did decode; a framing error on the underlying stream stops the
+// read and is appended to errs.
+//
+// Frames are read a whole one at a time via FrameReader.NextFrame when the
+// Framer supports it, rather than by chunking through Read with a fixed
+// buffer: Read is free to return less than a full frame (a single call
+// never returns more than the caller's buffer, and the length-delimited
+// reader can also stop at what its bufio.Reader currently holds), and
+// treating each Read's output as a complete frame would silently corrupt
+// any object larger than that into several bogus ones. A Framer
+// with no concept of a frame boundary, like DefaultFramer, is read to EOF
+// once and treated as a single frame.
+func DecodeStreamList(r io.ReadCloser, contentType string, registry *FramerRegistry, decoders ...Decoder) ([]Object, []error) {
+	if registry == nil {
+		registry = DefaultFramerRegistry
+	}
+	framer, ok := registry.FramerFor(contentType)
+	if !ok {
+		framer = DefaultFramer
+	}
+	fr := framer.NewFrameReader(r)
+	defer fr.Close()
+
+	framed, isFramed := fr.(FrameReader)
+
+	var objects []Object
+	var errs []error
+	for {
+		var raw []byte
+		var err error
+		if isFramed {
+			raw, err = framed.NextFrame()
+		} else {
+			raw, err = ioutil.ReadAll(fr)
+			if err == nil {
+				err = io.EOF
+			}
+		}
+		if len(raw) > 0 {
+			obj, derr := decodeListItem(&Unknown{Raw: raw, ContentType: contentType}, decoders)
+			if derr != nil {
+				errs = append(errs, derr)
+			} else {
+				objects = append(objects, obj)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			break
 		}
 	}
-	return
+	return objects, errs
+}
+
+// MultiObjectTyper returns the types of objects across multiple schemes in
+// order, answering with the first typer that recognizes the object. It is a
+// thin alias over PriorityObjectTyper{Policy: FirstWins} kept so existing
+// callers that construct a bare []ObjectTyper don't break; new callers
+// that want disagreement between schemes to surface instead of being
+// decided by slice order should build a PriorityObjectTyper directly.
+type MultiObjectTyper []ObjectTyper
+
+var _ ObjectTyper = MultiObjectTyper{}
+
+func (m MultiObjectTyper) priority() *PriorityObjectTyper {
+	return &PriorityObjectTyper{Typers: []ObjectTyper(m), Policy: FirstWins}
+}
+
+func (m MultiObjectTyper) ObjectKind(obj Object) (unversioned.GroupVersionKind, error) {
+	return m.priority().ObjectKind(obj)
+}
+
+func (m MultiObjectTyper) ObjectKinds(obj Object) ([]unversioned.GroupVersionKind, error) {
+	return m.priority().ObjectKinds(obj)
 }
 
 func (m MultiObjectTyper) Recognizes(gvk unversioned.GroupVersionKind) bool {
@@ -184,12 +317,7 @@ func (m MultiObjectTyper) Recognizes(gvk unversioned.GroupVersionKind) bool {
 }
 
 func (m MultiObjectTyper) IsUnversioned(obj Object) (bool, bool) {
-	for _, t := range m {
-		if unversioned, ok := t.IsUnversioned(obj); ok {
-			return unversioned, true
-		}
-	}
-	return false, false
+	return m.priority().IsUnversioned(obj)
 }
 
 // SetZeroValue would set the object of objPtr to zero value of its type.
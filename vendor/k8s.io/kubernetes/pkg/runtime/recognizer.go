@@ -0,0 +1,144 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+// recognizerPeekSize is the number of leading bytes sniffed from a payload
+// to decide which decoder should handle it. It is generous enough to skip
+// leading whitespace and see past a magic prefix like protobuf's "k8s\x00",
+// but small enough to avoid buffering large payloads just to sniff them.
+const recognizerPeekSize = 32
+
+// RecognizingDecoder is implemented by Decoders that can cheaply determine,
+// from only a small prefix of a payload, whether it is in the format they
+// decode. This lets callers dispatch directly to the right decoder instead
+// of trying every registered decoder in turn until one doesn't error.
+type RecognizingDecoder interface {
+	Decoder
+	// RecognizesData peeks at the leading bytes of a payload and reports
+	// whether this decoder recognizes the format (ok), whether too little
+	// data was available to tell (unknown), or a non-nil error if peek
+	// itself is malformed for this format (for example truncated magic
+	// bytes).
+	RecognizesData(peek []byte) (ok, unknown bool, err error)
+}
+
+// decodeRecognized asks every decoder in decoders that implements
+// RecognizingDecoder whether it recognizes data, and decodes with the
+// first one that claims it. The second return value reports whether any
+// decoder recognized the data at all, or rejected it with an error from
+// RecognizesData; callers should fall back to their own trial-and-error
+// strategy when it is false.
+func decodeRecognized(data []byte, decoders []Decoder) (Object, bool, error) {
+	peek := data
+	if len(peek) > recognizerPeekSize {
+		peek = peek[:recognizerPeekSize]
+	}
+	for _, decoder := range decoders {
+		recognizer, ok := decoder.(RecognizingDecoder)
+		if !ok {
+			continue
+		}
+		recognized, unknown, err := recognizer.RecognizesData(peek)
+		if err != nil {
+			return nil, true, err
+		}
+		if unknown || !recognized {
+			continue
+		}
+		obj, err := Decode(decoder, data)
+		return obj, true, err
+	}
+	return nil, false, nil
+}
+
+// NewRecognizingDecoder returns a Decoder that dispatches directly to
+// whichever of decoders recognizes a payload's leading bytes, and only
+// falls back to trying each decoder in turn (decodeListItem's historic
+// behavior) when none of them can sniff the format.
+func NewRecognizingDecoder(decoders []Decoder) Decoder {
+	return &recognizingDecoder{decoders: decoders}
+}
+
+type recognizingDecoder struct {
+	decoders []Decoder
+}
+
+func (d *recognizingDecoder) Decode(data []byte, defaults *unversioned.GroupVersionKind, into Object) (Object, *unversioned.GroupVersionKind, error) {
+	peek := data
+	if len(peek) > recognizerPeekSize {
+		peek = peek[:recognizerPeekSize]
+	}
+	for _, decoder := range d.decoders {
+		recognizer, ok := decoder.(RecognizingDecoder)
+		if !ok {
+			continue
+		}
+		recognized, unknown, err := recognizer.RecognizesData(peek)
+		if err != nil {
+			return nil, nil, err
+		}
+		if unknown || !recognized {
+			continue
+		}
+		return decoder.Decode(data, defaults, into)
+	}
+	for _, decoder := range d.decoders {
+		obj, gvk, err := decoder.Decode(data, defaults, into)
+		if err != nil {
+			if IsNotRegisteredError(err) {
+				continue
+			}
+			return nil, nil, err
+		}
+		return obj, gvk, nil
+	}
+	return nil, nil, fmt.Errorf("none of the %d configured decoders recognized this data", len(d.decoders))
+}
+
+// IsJSONBuffer scans the leading bytes of buf, skipping whitespace, and
+// reports whether it looks like a JSON object or array, for use as the
+// RecognizesData check of a JSON decoder.
+func IsJSONBuffer(buf []byte) bool {
+	trim := bytes.TrimLeftFunc(buf, unicode.IsSpace)
+	return bytes.HasPrefix(trim, []byte("{")) || bytes.HasPrefix(trim, []byte("["))
+}
+
+// IsYAMLBuffer scans the leading bytes of buf and reports whether it looks
+// like a YAML document: either an explicit "---" document marker, or a
+// bare "key: value" mapping line where a JSON decoder would not match.
+func IsYAMLBuffer(buf []byte) bool {
+	trim := bytes.TrimLeftFunc(buf, unicode.IsSpace)
+	if bytes.HasPrefix(trim, []byte("---")) {
+		return true
+	}
+	if len(trim) == 0 || IsJSONBuffer(trim) {
+		return false
+	}
+	line := trim
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return bytes.ContainsRune(line, ':')
+}
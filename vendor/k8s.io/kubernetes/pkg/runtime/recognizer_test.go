@@ -0,0 +1,109 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+// fakeSniffDecoder recognizes payloads starting with want, and on Decode
+// returns a marker object so tests can tell which decoder handled a call.
+type fakeSniffDecoder struct {
+	name string
+	want []byte
+}
+
+var _ RecognizingDecoder = fakeSniffDecoder{}
+
+func (d fakeSniffDecoder) RecognizesData(peek []byte) (ok, unknown bool, err error) {
+	if len(peek) < len(d.want) {
+		return false, true, nil
+	}
+	for i := range d.want {
+		if peek[i] != d.want[i] {
+			return false, false, nil
+		}
+	}
+	return true, false, nil
+}
+
+func (d fakeSniffDecoder) Decode(data []byte, defaults *unversioned.GroupVersionKind, into Object) (Object, *unversioned.GroupVersionKind, error) {
+	return nil, nil, fmt.Errorf("decoded by %s", d.name)
+}
+
+func TestDecodeRecognizedDispatchesToMatchingDecoder(t *testing.T) {
+	decoders := []Decoder{
+		fakeSniffDecoder{name: "json", want: []byte("{")},
+		fakeSniffDecoder{name: "proto", want: []byte("k8s\x00")},
+	}
+
+	_, ok, err := decodeRecognized([]byte("k8s\x00rest-of-the-message"), decoders)
+	if !ok {
+		t.Fatalf("expected a decoder to recognize the protobuf-prefixed payload")
+	}
+	if err == nil || err.Error() != "decoded by proto" {
+		t.Errorf("expected dispatch to the proto decoder, got err=%v", err)
+	}
+}
+
+func TestDecodeRecognizedNoMatch(t *testing.T) {
+	decoders := []Decoder{
+		fakeSniffDecoder{name: "json", want: []byte("{")},
+	}
+	_, ok, err := decodeRecognized([]byte("---\nkind: Pod"), decoders)
+	if ok {
+		t.Fatalf("expected no decoder to recognize a YAML payload, got ok=true err=%v", err)
+	}
+}
+
+func TestIsJSONBuffer(t *testing.T) {
+	cases := []struct {
+		in   string
+		want bool
+	}{
+		{`{"a":1}`, true},
+		{"   \t\n[1,2,3]", true},
+		{"---\nkind: Pod", false},
+		{"kind: Pod\n", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := IsJSONBuffer([]byte(c.in)); got != c.want {
+			t.Errorf("IsJSONBuffer(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestIsYAMLBuffer(t *testing.T) {
+	cases := []struct {
+		in   string
+		want bool
+	}{
+		{"---\nkind: Pod", true},
+		{"kind: Pod\nmetadata:\n  name: x", true},
+		{`{"kind":"Pod"}`, false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := IsYAMLBuffer([]byte(c.in)); got != c.want {
+			t.Errorf("IsYAMLBuffer(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protobuf
+
+import (
+	"bytes"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+// fakeObject is a minimal stand-in for a generated protobuf type: it
+// carries its own TypeMeta (so it satisfies runtime.Object) and
+// implements proto.Message well enough for the gogo reflection-based
+// marshaler to round-trip its one field.
+type fakeObject struct {
+	unversioned.TypeMeta `json:",inline"`
+	Value                string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *fakeObject) Reset()                                { *m = fakeObject{} }
+func (m *fakeObject) String() string                        { return m.Value }
+func (m *fakeObject) ProtoMessage()                         {}
+func (m *fakeObject) GetObjectKind() unversioned.ObjectKind { return &m.TypeMeta }
+
+type fakeCreater struct {
+	obj runtime.Object
+	err error
+}
+
+func (c fakeCreater) New(kind unversioned.GroupVersionKind) (runtime.Object, error) {
+	return c.obj, c.err
+}
+
+type fakeTyper struct {
+	gvk *unversioned.GroupVersionKind
+}
+
+func (t fakeTyper) ObjectKind(obj runtime.Object) (*unversioned.GroupVersionKind, bool, error) {
+	return t.gvk, false, nil
+}
+
+func TestSerializerRecognizesMagicPrefix(t *testing.T) {
+	s := NewSerializer(nil, nil, "application/vnd.kubernetes.protobuf")
+
+	ok, unknown, err := s.RecognizesData([]byte("k8s\x00rest"))
+	if err != nil || unknown || !ok {
+		t.Fatalf("RecognizesData(magic) = %v, %v, %v; want true, false, nil", ok, unknown, err)
+	}
+
+	ok, unknown, err = s.RecognizesData([]byte(`{"kind":"Pod"}`))
+	if err != nil || unknown || ok {
+		t.Fatalf("RecognizesData(json) = %v, %v, %v; want false, false, nil", ok, unknown, err)
+	}
+
+	ok, unknown, err = s.RecognizesData([]byte("k8"))
+	if err != nil || !unknown || ok {
+		t.Fatalf("RecognizesData(short) = %v, %v, %v; want false, true, nil", ok, unknown, err)
+	}
+}
+
+func TestSerializerEncodeDecodeRoundTrip(t *testing.T) {
+	gvk := unversioned.GroupVersionKind{Kind: "Widget", Version: "v1"}
+	obj := &fakeObject{Value: "hello"}
+
+	s := NewSerializer(fakeCreater{obj: &fakeObject{}}, fakeTyper{gvk: &gvk}, "application/vnd.kubernetes.protobuf")
+
+	var encoded []byte
+	if err := s.Encode(obj, func(data []byte) error {
+		encoded = append([]byte(nil), data...)
+		return nil
+	}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if !bytes.HasPrefix(encoded, protoEncodingPrefix) {
+		t.Fatalf("encoded data missing magic prefix: %x", encoded)
+	}
+
+	decoded, decodedGVK, err := s.Decode(encoded, nil, nil)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decodedGVK == nil || decodedGVK.Kind != "Widget" {
+		t.Errorf("Decode() gvk = %v, want Kind=Widget", decodedGVK)
+	}
+	got, ok := decoded.(*fakeObject)
+	if !ok {
+		t.Fatalf("Decode() returned %T, want *fakeObject", decoded)
+	}
+	if got.Value != "hello" {
+		t.Errorf("Decode().Value = %q, want %q", got.Value, "hello")
+	}
+}
+
+func TestSerializerDecodeRejectsMissingMagicPrefix(t *testing.T) {
+	s := NewSerializer(fakeCreater{}, fakeTyper{}, "application/vnd.kubernetes.protobuf")
+	_, _, err := s.Decode([]byte(`{"kind":"Pod"}`), nil, nil)
+	if err != ErrNotProtobuf {
+		t.Fatalf("Decode() err = %v, want ErrNotProtobuf", err)
+	}
+}
+
+func TestRawSerializerRoundTrip(t *testing.T) {
+	obj := &fakeObject{Value: "raw"}
+	s := NewRawSerializer(fakeCreater{obj: &fakeObject{}})
+
+	var encoded []byte
+	if err := s.Encode(obj, func(data []byte) error {
+		encoded = append([]byte(nil), data...)
+		return nil
+	}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	into := &fakeObject{}
+	decoded, _, err := s.Decode(encoded, nil, into)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.(*fakeObject).Value != "raw" {
+		t.Errorf("Decode().Value = %q, want %q", decoded.(*fakeObject).Value, "raw")
+	}
+}
+
+func TestRawSerializerDecodeRejectsMissingMagicPrefix(t *testing.T) {
+	s := NewRawSerializer(fakeCreater{})
+	_, _, err := s.Decode([]byte("not-protobuf-at-all"), nil, &fakeObject{})
+	if err != ErrNotProtobuf {
+		t.Fatalf("Decode() err = %v, want ErrNotProtobuf", err)
+	}
+}
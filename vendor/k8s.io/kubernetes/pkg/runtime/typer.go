@@ -0,0 +1,204 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"fmt"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+// Conflict describes how a PriorityObjectTyper should resolve disagreement
+// between its constituent ObjectTypers about the same object.
+type Conflict int
+
+const (
+	// FirstWins returns the first typer's answer and ignores the rest,
+	// matching MultiObjectTyper's historic behavior.
+	FirstWins Conflict = iota
+	// LastWins returns the last typer's answer, so later entries in
+	// Typers take priority over earlier ones.
+	LastWins
+	// Error returns an AmbiguousKindError naming every candidate typer
+	// and GroupVersionKind when two typers disagree about an object.
+	Error
+	// Merge unions every GroupVersionKind recognized by any typer,
+	// deduplicating identical entries, instead of picking one.
+	Merge
+)
+
+// AmbiguousKindError is returned by a PriorityObjectTyper configured with
+// the Error Conflict policy when more than one of its Typers claims a
+// different GroupVersionKind for the same object. It is a sign that two
+// schemes registered overlapping types and should not be merged silently.
+type AmbiguousKindError struct {
+	Object Object
+	Kinds  []unversioned.GroupVersionKind
+	Typers []ObjectTyper
+}
+
+func (e *AmbiguousKindError) Error() string {
+	return fmt.Sprintf("%T is recognized as %v by %d different typers; this usually means two schemes registered the same type", e.Object, e.Kinds, len(e.Typers))
+}
+
+// IsAmbiguousKindError returns true if err is an *AmbiguousKindError.
+func IsAmbiguousKindError(err error) bool {
+	_, ok := err.(*AmbiguousKindError)
+	return ok
+}
+
+// PriorityObjectTyper returns the types of objects across multiple
+// ObjectTypers, resolving disagreement between them according to Policy.
+// It supersedes MultiObjectTyper's implicit FirstWins-by-slice-order
+// behavior by making the conflict-resolution strategy explicit.
+type PriorityObjectTyper struct {
+	Typers []ObjectTyper
+	Policy Conflict
+}
+
+var _ ObjectTyper = &PriorityObjectTyper{}
+
+func (m *PriorityObjectTyper) ObjectKind(obj Object) (unversioned.GroupVersionKind, error) {
+	var kinds []unversioned.GroupVersionKind
+	var typers []ObjectTyper
+	var lastErr error
+	for _, t := range m.Typers {
+		gvk, err := t.ObjectKind(obj)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		kinds = append(kinds, gvk)
+		typers = append(typers, t)
+	}
+	if len(kinds) == 0 {
+		// Propagate the last typer's own error (as MultiObjectTyper always
+		// did) rather than synthesizing a new one, so callers checking
+		// things like IsNotRegisteredError(err) keep working.
+		return unversioned.GroupVersionKind{}, lastErr
+	}
+	switch m.Policy {
+	case LastWins:
+		return kinds[len(kinds)-1], nil
+	case Error, Merge:
+		for _, k := range kinds[1:] {
+			if k != kinds[0] {
+				if m.Policy == Error {
+					return unversioned.GroupVersionKind{}, &AmbiguousKindError{Object: obj, Kinds: kinds, Typers: typers}
+				}
+				// Merge has no single kind to return from ObjectKind;
+				// fall back to the first candidate, same as FirstWins.
+				break
+			}
+		}
+		return kinds[0], nil
+	default: // FirstWins
+		return kinds[0], nil
+	}
+}
+
+func (m *PriorityObjectTyper) ObjectKinds(obj Object) ([]unversioned.GroupVersionKind, error) {
+	var candidates [][]unversioned.GroupVersionKind
+	var typers []ObjectTyper
+	var lastErr error
+	for _, t := range m.Typers {
+		gvks, err := t.ObjectKinds(obj)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		candidates = append(candidates, gvks)
+		typers = append(typers, t)
+	}
+	if len(candidates) == 0 {
+		// As in ObjectKind, propagate the last typer's own error instead of
+		// a synthesized one.
+		return nil, lastErr
+	}
+	switch m.Policy {
+	case LastWins:
+		return candidates[len(candidates)-1], nil
+	case Merge:
+		seen := make(map[unversioned.GroupVersionKind]bool)
+		var merged []unversioned.GroupVersionKind
+		for _, gvks := range candidates {
+			for _, gvk := range gvks {
+				if !seen[gvk] {
+					seen[gvk] = true
+					merged = append(merged, gvk)
+				}
+			}
+		}
+		return merged, nil
+	case Error:
+		for _, gvks := range candidates[1:] {
+			if !gvkSlicesEqual(candidates[0], gvks) {
+				var all []unversioned.GroupVersionKind
+				for _, c := range candidates {
+					all = append(all, c...)
+				}
+				return nil, &AmbiguousKindError{Object: obj, Kinds: all, Typers: typers}
+			}
+		}
+		return candidates[0], nil
+	default: // FirstWins
+		return candidates[0], nil
+	}
+}
+
+func (m *PriorityObjectTyper) Recognizes(gvk unversioned.GroupVersionKind) bool {
+	for _, t := range m.Typers {
+		if t.Recognizes(gvk) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *PriorityObjectTyper) IsUnversioned(obj Object) (unversionedType bool, ok bool) {
+	switch m.Policy {
+	case LastWins:
+		for _, t := range m.Typers {
+			if u, o := t.IsUnversioned(obj); o {
+				unversionedType, ok = u, true
+			}
+		}
+		return
+	default:
+		// IsUnversioned has no error return to carry an AmbiguousKindError,
+		// so Error and Merge policies fall back to the first answer, same
+		// as FirstWins.
+		for _, t := range m.Typers {
+			if u, o := t.IsUnversioned(obj); o {
+				return u, true
+			}
+		}
+		return false, false
+	}
+}
+
+func gvkSlicesEqual(a, b []unversioned.GroupVersionKind) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
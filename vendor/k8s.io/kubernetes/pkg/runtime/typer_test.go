@@ -0,0 +1,164 @@
+/*
+Copyright 2014 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package runtime
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"k8s.io/kubernetes/pkg/api/unversioned"
+)
+
+type fakeTyper struct {
+	kind  unversioned.GroupVersionKind
+	kinds []unversioned.GroupVersionKind
+	err   error
+}
+
+var _ ObjectTyper = fakeTyper{}
+
+func (t fakeTyper) ObjectKind(obj Object) (unversioned.GroupVersionKind, error) {
+	return t.kind, t.err
+}
+
+func (t fakeTyper) ObjectKinds(obj Object) ([]unversioned.GroupVersionKind, error) {
+	return t.kinds, t.err
+}
+
+func (t fakeTyper) Recognizes(gvk unversioned.GroupVersionKind) bool {
+	return t.kind == gvk
+}
+
+func (t fakeTyper) IsUnversioned(obj Object) (bool, bool) {
+	return false, t.err == nil
+}
+
+var (
+	podKind    = unversioned.GroupVersionKind{Kind: "Pod", Version: "v1"}
+	crdKind    = unversioned.GroupVersionKind{Kind: "Widget", Group: "example.com", Version: "v1"}
+	errNoMatch = errors.New("no match for this typer")
+)
+
+func TestPriorityObjectTyperFirstWins(t *testing.T) {
+	p := &PriorityObjectTyper{
+		Typers: []ObjectTyper{
+			fakeTyper{kind: podKind},
+			fakeTyper{kind: crdKind},
+		},
+		Policy: FirstWins,
+	}
+	gvk, err := p.ObjectKind(nil)
+	if err != nil || gvk != podKind {
+		t.Fatalf("ObjectKind() = %v, %v; want %v, nil", gvk, err, podKind)
+	}
+}
+
+func TestPriorityObjectTyperLastWins(t *testing.T) {
+	p := &PriorityObjectTyper{
+		Typers: []ObjectTyper{
+			fakeTyper{kind: podKind},
+			fakeTyper{kind: crdKind},
+		},
+		Policy: LastWins,
+	}
+	gvk, err := p.ObjectKind(nil)
+	if err != nil || gvk != crdKind {
+		t.Fatalf("ObjectKind() = %v, %v; want %v, nil", gvk, err, crdKind)
+	}
+}
+
+func TestPriorityObjectTyperErrorOnConflict(t *testing.T) {
+	p := &PriorityObjectTyper{
+		Typers: []ObjectTyper{
+			fakeTyper{kind: podKind},
+			fakeTyper{kind: crdKind},
+		},
+		Policy: Error,
+	}
+	_, err := p.ObjectKind(nil)
+	if !IsAmbiguousKindError(err) {
+		t.Fatalf("ObjectKind() err = %v, want an AmbiguousKindError", err)
+	}
+	ambiguous := err.(*AmbiguousKindError)
+	if !reflect.DeepEqual(ambiguous.Kinds, []unversioned.GroupVersionKind{podKind, crdKind}) {
+		t.Errorf("AmbiguousKindError.Kinds = %v, want both candidates", ambiguous.Kinds)
+	}
+}
+
+func TestPriorityObjectTyperErrorAgreement(t *testing.T) {
+	p := &PriorityObjectTyper{
+		Typers: []ObjectTyper{
+			fakeTyper{kind: podKind},
+			fakeTyper{kind: podKind},
+		},
+		Policy: Error,
+	}
+	gvk, err := p.ObjectKind(nil)
+	if err != nil || gvk != podKind {
+		t.Fatalf("ObjectKind() = %v, %v; want %v, nil when typers agree", gvk, err, podKind)
+	}
+}
+
+func TestPriorityObjectTyperMergeObjectKinds(t *testing.T) {
+	p := &PriorityObjectTyper{
+		Typers: []ObjectTyper{
+			fakeTyper{kinds: []unversioned.GroupVersionKind{podKind, crdKind}},
+			fakeTyper{kinds: []unversioned.GroupVersionKind{crdKind}},
+		},
+		Policy: Merge,
+	}
+	gvks, err := p.ObjectKinds(nil)
+	if err != nil {
+		t.Fatalf("ObjectKinds(): %v", err)
+	}
+	want := []unversioned.GroupVersionKind{podKind, crdKind}
+	if !reflect.DeepEqual(gvks, want) {
+		t.Errorf("ObjectKinds() = %v, want deduplicated union %v", gvks, want)
+	}
+}
+
+func TestPriorityObjectTyperPropagatesUnderlyingError(t *testing.T) {
+	p := &PriorityObjectTyper{
+		Typers: []ObjectTyper{
+			fakeTyper{err: errNoMatch},
+		},
+		Policy: FirstWins,
+	}
+	if _, err := p.ObjectKind(nil); err != errNoMatch {
+		t.Fatalf("ObjectKind() err = %v, want the underlying typer's own error %v", err, errNoMatch)
+	}
+	if _, err := p.ObjectKinds(nil); err != errNoMatch {
+		t.Fatalf("ObjectKinds() err = %v, want the underlying typer's own error %v", err, errNoMatch)
+	}
+}
+
+func TestMultiObjectTyperIsAliasForFirstWins(t *testing.T) {
+	m := MultiObjectTyper{
+		fakeTyper{kind: podKind},
+		fakeTyper{kind: crdKind},
+	}
+	gvk, err := m.ObjectKind(nil)
+	if err != nil || gvk != podKind {
+		t.Fatalf("MultiObjectTyper.ObjectKind() = %v, %v; want %v, nil", gvk, err, podKind)
+	}
+
+	m = MultiObjectTyper{fakeTyper{err: errNoMatch}}
+	if _, err := m.ObjectKind(nil); err != errNoMatch {
+		t.Fatalf("MultiObjectTyper.ObjectKind() err = %v, want %v so existing callers like IsNotRegisteredError keep working", err, errNoMatch)
+	}
+}